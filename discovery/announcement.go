@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// Record is a DNS record a peer wants other peers to publish on its
+// behalf, e.g. ("my-laptop", 192.168.1.42, 300).
+type Record struct {
+	Hostname string `json:"hostname"`
+	Address  net.IP `json:"address"`
+	TTL      uint32 `json:"ttl"`
+}
+
+// announcement is the wire message broadcast by a peer to advertise its
+// presence. It is encoded as a 4-byte big-endian length prefix followed by
+// a JSON payload, which keeps the wire format simple to inspect while still
+// being unambiguous to frame on a stream-oriented transport.
+//
+// PeerID is derived from PublicKey (see peerIDFromPublicKey), so the
+// announcement is self-certifying: a receiver doesn't need to have seen a
+// peer before to know that whoever is claiming a given ID actually holds
+// the matching private key.
+type announcement struct {
+	PeerID     string            `json:"peer_id"`
+	PublicKey  ed25519.PublicKey `json:"public_key"`
+	Seq        uint64            `json:"seq"`
+	ListenPort uint16            `json:"listen_port"`
+	Records    []Record          `json:"records,omitempty"`
+}
+
+// peerIDFromPublicKey derives the stable identifier a peer announces itself
+// as. It's just the hex encoding of the public key, which keeps "lowest
+// peer ID wins" tiebreakers (used by the election package) deterministic
+// and easy to reason about.
+func peerIDFromPublicKey(pub ed25519.PublicKey) string {
+	return hex.EncodeToString(pub)
+}
+
+// signedAnnouncement is what actually goes on the wire: the announcement
+// payload plus an Ed25519 signature over it, so a receiver can reject
+// spoofed or replayed broadcasts from peers it doesn't recognise.
+type signedAnnouncement struct {
+	Announcement announcement `json:"announcement"`
+	Signature    []byte       `json:"signature"`
+}
+
+// payloadToSign returns the canonical bytes that are signed and verified.
+// It re-marshals the announcement rather than signing the raw wire bytes so
+// that signature verification doesn't depend on JSON field ordering.
+func (a announcement) payloadToSign() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// sign produces a signedAnnouncement for a, using priv as the signing key.
+func sign(a announcement, priv ed25519.PrivateKey) (signedAnnouncement, error) {
+	payload, err := a.payloadToSign()
+	if err != nil {
+		return signedAnnouncement{}, fmt.Errorf("marshal announcement: %w", err)
+	}
+	return signedAnnouncement{
+		Announcement: a,
+		Signature:    ed25519.Sign(priv, payload),
+	}, nil
+}
+
+// verify checks that sa is internally consistent: its PeerID matches its
+// PublicKey, and its Signature was produced by that key over the
+// announcement payload.
+func (sa signedAnnouncement) verify() error {
+	if got := peerIDFromPublicKey(sa.Announcement.PublicKey); got != sa.Announcement.PeerID {
+		return fmt.Errorf("peer ID %q does not match public key (got %q)", sa.Announcement.PeerID, got)
+	}
+	payload, err := sa.Announcement.payloadToSign()
+	if err != nil {
+		return fmt.Errorf("marshal announcement: %w", err)
+	}
+	if !ed25519.Verify(sa.Announcement.PublicKey, payload, sa.Signature) {
+		return fmt.Errorf("invalid signature for peer %q", sa.Announcement.PeerID)
+	}
+	return nil
+}
+
+// encode frames sa as a length-prefixed JSON blob suitable for a single UDP
+// datagram.
+func encode(sa signedAnnouncement) ([]byte, error) {
+	body, err := json.Marshal(sa)
+	if err != nil {
+		return nil, fmt.Errorf("marshal signed announcement: %w", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, uint32(len(body))); err != nil {
+		return nil, fmt.Errorf("write length prefix: %w", err)
+	}
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// decode reverses encode, validating that the declared length matches what
+// was actually received.
+func decode(raw []byte) (signedAnnouncement, error) {
+	if len(raw) < 4 {
+		return signedAnnouncement{}, fmt.Errorf("datagram too short: %d bytes", len(raw))
+	}
+	length := binary.BigEndian.Uint32(raw[:4])
+	if int(length) != len(raw)-4 {
+		return signedAnnouncement{}, fmt.Errorf("length prefix %d does not match body of %d bytes", length, len(raw)-4)
+	}
+	var sa signedAnnouncement
+	if err := json.Unmarshal(raw[4:], &sa); err != nil {
+		return signedAnnouncement{}, fmt.Errorf("unmarshal signed announcement: %w", err)
+	}
+	return sa, nil
+}
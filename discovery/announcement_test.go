@@ -0,0 +1,119 @@
+package discovery
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+func mustKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %s", err)
+	}
+	return pub, priv
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	pub, priv := mustKey(t)
+	a := announcement{
+		PeerID:     peerIDFromPublicKey(pub),
+		PublicKey:  pub,
+		Seq:        1,
+		ListenPort: 2002,
+	}
+
+	sa, err := sign(a, priv)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if err := sa.verify(); err != nil {
+		t.Fatalf("verify: %s", err)
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	pub, priv := mustKey(t)
+	a := announcement{PeerID: peerIDFromPublicKey(pub), PublicKey: pub, Seq: 1, ListenPort: 2002}
+	sa, err := sign(a, priv)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+
+	sa.Announcement.Seq = 99 // tamper after signing
+	if err := sa.verify(); err == nil {
+		t.Fatalf("verify succeeded for a tampered announcement")
+	}
+}
+
+func TestVerifyRejectsMismatchedPeerID(t *testing.T) {
+	pub, priv := mustKey(t)
+	otherPub, _ := mustKey(t)
+	a := announcement{PeerID: peerIDFromPublicKey(otherPub), PublicKey: pub, Seq: 1, ListenPort: 2002}
+	sa, err := sign(a, priv)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if err := sa.verify(); err == nil {
+		t.Fatalf("verify succeeded when PeerID doesn't match PublicKey")
+	}
+}
+
+func TestVerifyRejectsForeignSignature(t *testing.T) {
+	pub, _ := mustKey(t)
+	_, otherPriv := mustKey(t)
+	a := announcement{PeerID: peerIDFromPublicKey(pub), PublicKey: pub, Seq: 1, ListenPort: 2002}
+	// Sign with a key that doesn't match the claimed PublicKey.
+	sa, err := sign(a, otherPriv)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+	if err := sa.verify(); err == nil {
+		t.Fatalf("verify succeeded for a signature from an unrelated key")
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	pub, priv := mustKey(t)
+	a := announcement{
+		PeerID:     peerIDFromPublicKey(pub),
+		PublicKey:  pub,
+		Seq:        42,
+		ListenPort: 2002,
+		Records:    []Record{{Hostname: "box", TTL: 300}},
+	}
+	sa, err := sign(a, priv)
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+
+	raw, err := encode(sa)
+	if err != nil {
+		t.Fatalf("encode: %s", err)
+	}
+
+	got, err := decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %s", err)
+	}
+	if got.Announcement.PeerID != a.PeerID || got.Announcement.Seq != a.Seq {
+		t.Fatalf("got %+v, want round-tripped %+v", got.Announcement, a)
+	}
+	if err := got.verify(); err != nil {
+		t.Fatalf("verify after round-trip: %s", err)
+	}
+}
+
+func TestDecodeRejectsShortDatagram(t *testing.T) {
+	if _, err := decode([]byte{0, 1}); err == nil {
+		t.Fatalf("decode succeeded for a datagram shorter than the length prefix")
+	}
+}
+
+func TestDecodeRejectsMismatchedLengthPrefix(t *testing.T) {
+	raw := []byte{0, 0, 0, 100, 'x'} // claims 100 bytes, has 1
+	if _, err := decode(raw); err == nil {
+		t.Fatalf("decode succeeded despite a length prefix that doesn't match the body")
+	}
+}
@@ -0,0 +1,375 @@
+// Package discovery implements LAN peer discovery over UDP broadcast.
+//
+// Peers periodically broadcast a signed announcement of themselves. Anyone
+// listening builds up a table of recently-heard peers, evicting entries
+// once they've gone quiet for too long. See peer/peer.go for a minimal
+// example of driving this package directly.
+package discovery
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Peer is a snapshot of what we know about another peer on the network.
+type Peer struct {
+	ID       string
+	Addr     *net.UDPAddr
+	LastSeen time.Time
+	// Records are the DNS records this peer most recently announced, if
+	// any. See the nameserver package for how these get merged into a
+	// zone.
+	Records []Record
+
+	lastSeq uint64 // last announcement sequence number accepted from this peer
+}
+
+func (p *Peer) seq() uint64     { return p.lastSeq }
+func (p *Peer) setSeq(n uint64) { p.lastSeq = n }
+
+// EventType identifies the kind of change reported on the Discovery event
+// channel.
+type EventType int
+
+const (
+	// PeerAdded is emitted the first time a peer ID is heard from.
+	PeerAdded EventType = iota
+	// PeerLost is emitted when a peer is evicted for being stale.
+	PeerLost
+)
+
+// Event describes a single peer-table membership change.
+type Event struct {
+	Type EventType
+	Peer Peer
+}
+
+// Config controls how a Discovery instance behaves. The zero value is not
+// valid; use New, which fills in defaults for anything left unset.
+type Config struct {
+	// Port is the UDP port peers broadcast to and listen on.
+	Port int
+	// BroadcastInterval is how often we announce ourselves. Defaults to 1s.
+	BroadcastInterval time.Duration
+	// StaleAfter is how long a peer may go unheard from before it's
+	// considered gone. Defaults to 10x BroadcastInterval.
+	StaleAfter time.Duration
+	// PruneInterval is how often the peer table is checked for stale
+	// entries. Defaults to StaleAfter / 2.
+	PruneInterval time.Duration
+	// PrivateKey is the identity this instance announces under. A fresh
+	// one is generated if left nil.
+	PrivateKey ed25519.PrivateKey
+	// Workers is how many goroutines decode and process received
+	// datagrams. Defaults to 4.
+	Workers int
+	// QueueSize bounds how many received-but-not-yet-processed datagrams
+	// may be buffered before new ones are dropped. Defaults to 256.
+	QueueSize int
+}
+
+func (c *Config) setDefaults() error {
+	if c.Port == 0 {
+		return fmt.Errorf("port must be set")
+	}
+	if c.BroadcastInterval == 0 {
+		c.BroadcastInterval = time.Second
+	}
+	if c.StaleAfter == 0 {
+		c.StaleAfter = 10 * c.BroadcastInterval
+	}
+	if c.PruneInterval == 0 {
+		c.PruneInterval = c.StaleAfter / 2
+	}
+	if c.PrivateKey == nil {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generate identity key: %w", err)
+		}
+		c.PrivateKey = priv
+	}
+	if c.Workers == 0 {
+		c.Workers = 4
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = 256
+	}
+	return nil
+}
+
+// Discovery maintains a table of peers discovered via signed UDP
+// broadcasts.
+type Discovery struct {
+	cfg    Config
+	selfID string
+	pub    ed25519.PublicKey
+	seq    uint64 // atomic
+
+	mu      sync.Mutex
+	peers   map[string]*Peer
+	records []Record // our own records, announced alongside presence
+
+	events chan Event
+
+	tr      *transport
+	selfIPs map[string]bool
+
+	m         metrics
+	workQueue chan datagram
+}
+
+// New constructs a Discovery instance. It does not start any network
+// activity until Start is called.
+func New(cfg Config) (*Discovery, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	pub := cfg.PrivateKey.Public().(ed25519.PublicKey)
+	return &Discovery{
+		cfg:    cfg,
+		selfID: peerIDFromPublicKey(pub),
+		pub:    pub,
+		peers:  make(map[string]*Peer),
+		events: make(chan Event, 16),
+	}, nil
+}
+
+// SelfID returns the peer ID this instance announces itself under.
+func (d *Discovery) SelfID() string {
+	return d.selfID
+}
+
+// SetRecords replaces the DNS records this instance announces alongside
+// its presence, taking effect from the next broadcast.
+func (d *Discovery) SetRecords(records []Record) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.records = records
+}
+
+// Events returns the channel on which PeerAdded/PeerLost events are
+// delivered. It is closed when ctx passed to Start is cancelled.
+func (d *Discovery) Events() <-chan Event {
+	return d.events
+}
+
+// Peers returns a point-in-time snapshot of the known peer table.
+func (d *Discovery) Peers() []Peer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Peer, 0, len(d.peers))
+	for _, p := range d.peers {
+		out = append(out, *p)
+	}
+	return out
+}
+
+// Start opens the broadcast transport, then runs the broadcast, receive
+// and prune loops until ctx is cancelled. It blocks until all loops have
+// exited.
+func (d *Discovery) Start(ctx context.Context) error {
+	tr, err := newTransport(d.cfg.Port)
+	if err != nil {
+		return fmt.Errorf("open transport: %w", err)
+	}
+	defer tr.Close()
+	d.tr = tr
+
+	selfIPs, err := localIPs()
+	if err != nil {
+		return fmt.Errorf("determine local addresses: %w", err)
+	}
+	d.selfIPs = selfIPs
+	d.workQueue = make(chan datagram, d.cfg.QueueSize)
+
+	var wg sync.WaitGroup
+	wg.Add(3 + d.cfg.Workers)
+	go func() { defer wg.Done(); d.broadcastLoop(ctx) }()
+	go func() { defer wg.Done(); d.receiveLoop(ctx) }()
+	go func() { defer wg.Done(); d.pruneLoop(ctx) }()
+	for i := 0; i < d.cfg.Workers; i++ {
+		go func() { defer wg.Done(); d.workerLoop(ctx) }()
+	}
+	wg.Wait()
+	close(d.events)
+	return nil
+}
+
+// workerLoop dispatches queued datagrams to worker until ctx is cancelled.
+// Running a fixed number of these, rather than a goroutine per datagram,
+// keeps a broadcast storm from turning into a goroutine explosion.
+func (d *Discovery) workerLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dg := <-d.workQueue:
+			d.worker(dg)
+		}
+	}
+}
+
+// broadcastLoop periodically signs and sends an announcement of ourselves.
+func (d *Discovery) broadcastLoop(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.BroadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.broadcastOnce(); err != nil {
+				log.Printf("discovery: broadcast failed: %s", err)
+			}
+		}
+	}
+}
+
+func (d *Discovery) broadcastOnce() error {
+	seq := atomic.AddUint64(&d.seq, 1)
+	d.mu.Lock()
+	records := d.records
+	d.mu.Unlock()
+	a := announcement{
+		PeerID:     d.selfID,
+		PublicKey:  d.pub,
+		Seq:        seq,
+		ListenPort: uint16(d.cfg.Port),
+		Records:    records,
+	}
+	sa, err := sign(a, d.cfg.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("sign announcement: %w", err)
+	}
+	raw, err := encode(sa)
+	if err != nil {
+		return fmt.Errorf("encode announcement: %w", err)
+	}
+	if err := d.tr.broadcast(raw); err != nil {
+		return fmt.Errorf("broadcast: %w", err)
+	}
+	return nil
+}
+
+// receiveLoop only reads datagrams off the transport into a pooled buffer
+// and hands them to the worker pool; decoding, signature verification and
+// peer-table updates all happen in workerLoop so a single slow or
+// malicious sender can't stall the socket read.
+func (d *Discovery) receiveLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		buf := datagramBufs.Get().([]byte)
+		// A short deadline lets us notice ctx cancellation promptly
+		// instead of blocking forever in ReadFrom.
+		_ = d.tr.raw.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		n, ifIndex, addr, err := d.tr.readFrom(buf)
+		if err != nil {
+			datagramBufs.Put(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			log.Printf("discovery: read failed: %s", err)
+			continue
+		}
+		atomic.AddUint64(&d.m.packetsReceived, 1)
+		dg := datagram{buf: buf, n: n, addr: addr, ifIndex: ifIndex}
+		select {
+		case d.workQueue <- dg:
+		default:
+			atomic.AddUint64(&d.m.packetsDropped, 1)
+			datagramBufs.Put(buf)
+		}
+	}
+}
+
+func (d *Discovery) handleDatagram(raw []byte, addr *net.UDPAddr, ifIndex int) {
+	if d.isSelf(addr) {
+		return
+	}
+	sa, err := decode(raw)
+	if err != nil {
+		atomic.AddUint64(&d.m.parseErrors, 1)
+		log.Printf("discovery: dropping malformed datagram from %s (if %d): %s", addr, ifIndex, err)
+		return
+	}
+	if err := sa.verify(); err != nil {
+		atomic.AddUint64(&d.m.parseErrors, 1)
+		log.Printf("discovery: dropping unverifiable announcement from %s (if %d): %s", addr, ifIndex, err)
+		return
+	}
+	d.recordPeer(sa.Announcement, addr)
+}
+
+// isSelf reports whether addr belongs to one of our own interfaces,
+// which is what lets us ignore the copies of our own broadcasts that get
+// looped back to our listening socket.
+func (d *Discovery) isSelf(addr *net.UDPAddr) bool {
+	return d.selfIPs[addr.IP.String()]
+}
+
+func (d *Discovery) recordPeer(a announcement, addr *net.UDPAddr) {
+	now := time.Now()
+	d.mu.Lock()
+	existing, known := d.peers[a.PeerID]
+	if known && a.Seq <= existing.seq() {
+		d.mu.Unlock()
+		return // stale or replayed announcement
+	}
+	p := &Peer{ID: a.PeerID, Addr: addr, LastSeen: now, Records: a.Records}
+	p.setSeq(a.Seq)
+	d.peers[a.PeerID] = p
+	d.mu.Unlock()
+
+	if !known {
+		d.emit(Event{Type: PeerAdded, Peer: *p})
+	}
+}
+
+// pruneLoop periodically evicts peers that have gone stale.
+func (d *Discovery) pruneLoop(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.pruneOnce()
+		}
+	}
+}
+
+func (d *Discovery) pruneOnce() {
+	now := time.Now()
+	var lost []Peer
+	d.mu.Lock()
+	for id, p := range d.peers {
+		if now.Sub(p.LastSeen) > d.cfg.StaleAfter {
+			lost = append(lost, *p)
+			delete(d.peers, id)
+		}
+	}
+	d.mu.Unlock()
+	for _, p := range lost {
+		d.emit(Event{Type: PeerLost, Peer: p})
+	}
+}
+
+func (d *Discovery) emit(e Event) {
+	select {
+	case d.events <- e:
+	default:
+		log.Printf("discovery: event channel full, dropping %v event for %q", e.Type, e.Peer.ID)
+	}
+}
@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+)
+
+func newTestDiscovery(t *testing.T) *Discovery {
+	t.Helper()
+	d, err := New(Config{Port: 2002})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	return d
+}
+
+func TestRecordPeer_NewPeerEmitsAdded(t *testing.T) {
+	d := newTestDiscovery(t)
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2002}
+
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 1}, addr)
+
+	select {
+	case ev := <-d.events:
+		if ev.Type != PeerAdded || ev.Peer.ID != "peer-a" {
+			t.Fatalf("got %+v, want PeerAdded for peer-a", ev)
+		}
+	default:
+		t.Fatalf("expected a PeerAdded event, got none")
+	}
+	if got := d.Peers(); len(got) != 1 || got[0].ID != "peer-a" {
+		t.Fatalf("got peers %+v, want a single peer-a entry", got)
+	}
+}
+
+func TestRecordPeer_ReplayedSequenceIsIgnored(t *testing.T) {
+	d := newTestDiscovery(t)
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2002}
+
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 5, ListenPort: 2002}, addr)
+	<-d.events // drain the PeerAdded event
+
+	staleAddr := &net.UDPAddr{IP: net.ParseIP("10.0.0.99"), Port: 2002}
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 5}, staleAddr) // replay of the same seq
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 3}, staleAddr) // an older seq
+
+	got := d.Peers()
+	if len(got) != 1 {
+		t.Fatalf("got %d peers, want 1", len(got))
+	}
+	if !got[0].Addr.IP.Equal(addr.IP) {
+		t.Fatalf("got addr %s, want the replay/stale updates to be rejected and keep %s", got[0].Addr, addr)
+	}
+}
+
+func TestRecordPeer_HigherSequenceUpdatesPeer(t *testing.T) {
+	d := newTestDiscovery(t)
+	first := &net.UDPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2002}
+	second := &net.UDPAddr{IP: net.ParseIP("10.0.0.6"), Port: 2002}
+
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 1}, first)
+	<-d.events
+	d.recordPeer(announcement{PeerID: "peer-a", Seq: 2}, second)
+
+	got := d.Peers()
+	if len(got) != 1 || !got[0].Addr.IP.Equal(second.IP) {
+		t.Fatalf("got %+v, want peer-a updated to address %s", got, second)
+	}
+}
+
+func TestIsSelf(t *testing.T) {
+	d := newTestDiscovery(t)
+	d.selfIPs = map[string]bool{"10.0.0.1": true}
+
+	if !d.isSelf(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 2002}) {
+		t.Fatalf("expected an address matching a local interface to be treated as self")
+	}
+	if d.isSelf(&net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2002}) {
+		t.Fatalf("expected an address not matching any local interface to not be self")
+	}
+}
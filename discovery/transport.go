@@ -0,0 +1,185 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+)
+
+// transport is the per-process UDP broadcast socket. Unlike dialing
+// 255.255.255.255 (which fails, or silently picks an arbitrary interface,
+// on multi-homed hosts), it sends one directed broadcast per
+// broadcast-capable interface and uses IPv4 control messages on receive to
+// know which interface a datagram arrived on.
+type transport struct {
+	port int
+	pc   *ipv4.PacketConn
+	raw  net.PacketConn
+}
+
+// newTransport opens the shared send/receive socket for port.
+func newTransport(port int) (*transport, error) {
+	raw, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("listen udp4 :%d: %w", port, err)
+	}
+	if err := enableBroadcast(raw); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("enable SO_BROADCAST: %w", err)
+	}
+	pc := ipv4.NewPacketConn(raw)
+	if err := pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("enable interface control messages: %w", err)
+	}
+	return &transport{port: port, pc: pc, raw: raw}, nil
+}
+
+func (t *transport) Close() error {
+	return t.raw.Close()
+}
+
+// enableBroadcast sets SO_BROADCAST on the socket backing pc, since
+// net.ListenPacket gives us no portable way to do so and sending to a
+// broadcast address otherwise fails with EACCES/EPERM.
+func enableBroadcast(pc net.PacketConn) error {
+	sc, ok := pc.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("packet conn does not expose a raw syscall connection")
+	}
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("syscall conn: %w", err)
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	}); err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	return sockErr
+}
+
+// broadcastInterfaces returns the interfaces we should send directed
+// broadcasts on: up and broadcast-capable.
+func broadcastInterfaces() ([]net.Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %w", err)
+	}
+	out := make([]net.Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		out = append(out, iface)
+	}
+	return out, nil
+}
+
+// directedBroadcastAddr computes iface's IPv4 directed broadcast address,
+// e.g. 192.168.1.255 for an interface configured as 192.168.1.42/24.
+func directedBroadcastAddr(iface net.Interface, port int) (*net.UDPAddr, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("addrs for %s: %w", iface.Name, err)
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if addr, err := directedBroadcastAddrForNet(iface, ipNet, port); err == nil {
+			return addr, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no usable IPv4 address", iface.Name)
+}
+
+// directedBroadcastAddrForNet computes the directed broadcast address for
+// a single IPv4 network, e.g. 192.168.1.255 for 192.168.1.42/24. Split out
+// from directedBroadcastAddr so the bit-twiddling can be tested without a
+// real network interface.
+func directedBroadcastAddrForNet(iface net.Interface, ipNet *net.IPNet, port int) (*net.UDPAddr, error) {
+	ip4 := ipNet.IP.To4()
+	mask := net.IP(ipNet.Mask).To4()
+	if ip4 == nil || mask == nil {
+		return nil, fmt.Errorf("%s: %s is not an IPv4 network", iface.Name, ipNet)
+	}
+	bcast := make(net.IP, len(ip4))
+	for i := range ip4 {
+		bcast[i] = ip4[i] | ^mask[i]
+	}
+	return &net.UDPAddr{IP: bcast, Port: port}, nil
+}
+
+// localIPs returns every IPv4 address assigned to this host, used to
+// recognise our own broadcasts echoed back to us.
+func localIPs() (map[string]bool, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("list interface addrs: %w", err)
+	}
+	ips := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			ips[ip4.String()] = true
+		}
+	}
+	return ips, nil
+}
+
+// broadcast sends payload as a directed broadcast out every
+// broadcast-capable interface. It's considered successful as long as at
+// least one interface accepted the write.
+func (t *transport) broadcast(payload []byte) error {
+	ifaces, err := broadcastInterfaces()
+	if err != nil {
+		return err
+	}
+	if len(ifaces) == 0 {
+		return fmt.Errorf("no broadcast-capable interfaces found")
+	}
+	var errs []error
+	sent := 0
+	for _, iface := range ifaces {
+		addr, err := directedBroadcastAddr(iface, t.port)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cm := &ipv4.ControlMessage{IfIndex: iface.Index}
+		if _, err := t.pc.WriteTo(payload, cm, addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", iface.Name, err))
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return fmt.Errorf("broadcast failed on every interface: %v", errs)
+	}
+	return nil
+}
+
+// readFrom reads the next datagram, returning the index of the interface
+// it arrived on (0 if the kernel didn't tell us).
+func (t *transport) readFrom(buf []byte) (n int, ifIndex int, addr *net.UDPAddr, err error) {
+	n, cm, from, err := t.pc.ReadFrom(buf)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	udpAddr, ok := from.(*net.UDPAddr)
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("unexpected source address type %T", from)
+	}
+	if cm != nil {
+		ifIndex = cm.IfIndex
+	}
+	return n, ifIndex, udpAddr, nil
+}
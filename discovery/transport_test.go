@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDirectedBroadcastAddr(t *testing.T) {
+	cases := []struct {
+		name string
+		cidr string
+		port int
+		want string
+	}{
+		{"slash 24", "192.168.1.42/24", 2002, "192.168.1.255"},
+		{"slash 16", "10.0.1.2/16", 2002, "10.0.255.255"},
+		{"slash 30", "172.16.0.1/30", 2002, "172.16.0.3"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ip, ipNet, err := net.ParseCIDR(c.cidr)
+			if err != nil {
+				t.Fatalf("parse CIDR: %s", err)
+			}
+			ipNet.IP = ip // ParseCIDR zeroes the host bits; we want the full address
+			iface := net.Interface{Name: "fake0"}
+
+			addr, err := directedBroadcastAddrForNet(iface, ipNet, c.port)
+			if err != nil {
+				t.Fatalf("directedBroadcastAddrForNet: %s", err)
+			}
+			if addr.IP.String() != c.want || addr.Port != c.port {
+				t.Fatalf("got %s, want %s:%d", addr, c.want, c.port)
+			}
+		})
+	}
+}
+
+func TestDirectedBroadcastAddrRejectsIPv6(t *testing.T) {
+	_, ipNet, err := net.ParseCIDR("fe80::1/64")
+	if err != nil {
+		t.Fatalf("parse CIDR: %s", err)
+	}
+	if _, err := directedBroadcastAddrForNet(net.Interface{Name: "fake0"}, ipNet, 2002); err == nil {
+		t.Fatalf("expected an error for a non-IPv4 network")
+	}
+}
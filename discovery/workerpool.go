@@ -0,0 +1,61 @@
+package discovery
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// datagramBufs pools the fixed-size buffers datagrams are read into, so
+// the receive loop doesn't allocate on every packet.
+var datagramBufs = sync.Pool{
+	New: func() interface{} { return make([]byte, 2048) },
+}
+
+// datagram is a received-but-not-yet-processed packet, queued from the
+// receive loop to a worker.
+type datagram struct {
+	buf     []byte
+	n       int
+	addr    *net.UDPAddr
+	ifIndex int
+}
+
+// Metrics is a point-in-time snapshot of Discovery's packet-handling
+// counters.
+type Metrics struct {
+	// PacketsReceived counts datagrams read off the socket.
+	PacketsReceived uint64
+	// PacketsDropped counts datagrams discarded because the worker queue
+	// was full, e.g. during a broadcast storm.
+	PacketsDropped uint64
+	// ParseErrors counts datagrams that failed to decode or verify.
+	ParseErrors uint64
+}
+
+// metrics holds the live atomic counters backing Metrics.
+type metrics struct {
+	packetsReceived uint64
+	packetsDropped  uint64
+	parseErrors     uint64
+}
+
+func (m *metrics) snapshot() Metrics {
+	return Metrics{
+		PacketsReceived: atomic.LoadUint64(&m.packetsReceived),
+		PacketsDropped:  atomic.LoadUint64(&m.packetsDropped),
+		ParseErrors:     atomic.LoadUint64(&m.parseErrors),
+	}
+}
+
+// Metrics returns a snapshot of this instance's packet-handling counters.
+func (d *Discovery) Metrics() Metrics {
+	return d.m.snapshot()
+}
+
+// worker processes a single queued datagram and returns its buffer to
+// the pool. workerLoop calls it for each datagram it takes off the queue.
+func (d *Discovery) worker(dg datagram) {
+	defer datagramBufs.Put(dg.buf)
+	d.handleDatagram(dg.buf[:dg.n], dg.addr, dg.ifIndex)
+}
@@ -0,0 +1,129 @@
+// Package election implements a bully-style leader election on top of the
+// discovery package's peer table: the cluster member with the lowest peer
+// ID becomes primary, and anyone else is a backup. A re-election is
+// triggered whenever the current primary goes quiet for longer than a
+// configurable timeout.
+package election
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/astromechza/experiment-dhcp-nameservers/discovery"
+)
+
+// Role is a peer's current standing in the election.
+type Role int
+
+const (
+	// RoleBackup means some other peer is primary.
+	RoleBackup Role = iota
+	// RolePrimary means this peer has been elected primary.
+	RolePrimary
+)
+
+func (r Role) String() string {
+	if r == RolePrimary {
+		return "primary"
+	}
+	return "backup"
+}
+
+// defaultSilentTimeout is how long the current primary may go unheard
+// from before we consider it gone and re-run the election, if Options
+// doesn't say otherwise.
+const defaultSilentTimeout = 5 * time.Second
+
+// Options configures Start.
+type Options struct {
+	// SilentTimeout is how long the current primary may go unheard from
+	// before it's considered gone and a re-election is triggered.
+	// Defaults to 5s.
+	SilentTimeout time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.SilentTimeout == 0 {
+		o.SilentTimeout = defaultSilentTimeout
+	}
+}
+
+// peerSource is the subset of *discovery.Discovery the election loop
+// depends on. It's split out so tests can drive the algorithm against a
+// fake peer table instead of real sockets.
+type peerSource interface {
+	SelfID() string
+	Peers() []discovery.Peer
+}
+
+// Start runs the election loop against d with default Options until ctx
+// is cancelled, emitting a Role every time this instance's standing
+// changes. The returned channel is closed when the loop exits.
+func Start(ctx context.Context, d *discovery.Discovery) (<-chan Role, error) {
+	return StartWithOptions(ctx, d, Options{})
+}
+
+// StartWithOptions is Start with a configurable silent timeout.
+func StartWithOptions(ctx context.Context, d *discovery.Discovery, opts Options) (<-chan Role, error) {
+	opts.setDefaults()
+	return startWithTimeout(ctx, d, opts.SilentTimeout)
+}
+
+func startWithTimeout(ctx context.Context, d peerSource, silentTimeout time.Duration) (<-chan Role, error) {
+	roles := make(chan Role, 1)
+	go runLoop(ctx, d, silentTimeout, roles)
+	return roles, nil
+}
+
+func runLoop(ctx context.Context, d peerSource, silentTimeout time.Duration, roles chan<- Role) {
+	defer close(roles)
+
+	current := Role(-1) // force the first emit
+	checkInterval := silentTimeout / 5
+	if checkInterval <= 0 {
+		checkInterval = time.Second
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	emit := func(r Role) {
+		if r == current {
+			return
+		}
+		current = r
+		select {
+		case roles <- r:
+		case <-ctx.Done():
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			emit(elect(d, silentTimeout))
+		}
+	}
+}
+
+// elect determines this peer's role by picking the lowest peer ID among
+// everyone heard from within silentTimeout, including ourselves.
+func elect(d peerSource, silentTimeout time.Duration) Role {
+	lowest := d.SelfID()
+	now := time.Now()
+	for _, p := range d.Peers() {
+		if now.Sub(p.LastSeen) > silentTimeout {
+			continue // treat a silent peer as if it were gone
+		}
+		if p.ID < lowest {
+			lowest = p.ID
+		}
+	}
+	if lowest == d.SelfID() {
+		return RolePrimary
+	}
+	log.Printf("election: %s is primary", lowest)
+	return RoleBackup
+}
@@ -0,0 +1,163 @@
+package election
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/astromechza/experiment-dhcp-nameservers/discovery"
+)
+
+func TestOptions_SetDefaults(t *testing.T) {
+	var opts Options
+	opts.setDefaults()
+	if opts.SilentTimeout != defaultSilentTimeout {
+		t.Fatalf("got %s, want default %s", opts.SilentTimeout, defaultSilentTimeout)
+	}
+
+	opts = Options{SilentTimeout: 42 * time.Second}
+	opts.setDefaults()
+	if opts.SilentTimeout != 42*time.Second {
+		t.Fatalf("got %s, want explicit 42s preserved", opts.SilentTimeout)
+	}
+}
+
+func TestStartWithOptions_UsesConfiguredSilentTimeout(t *testing.T) {
+	d, err := discovery.New(discovery.Config{Port: 2002})
+	if err != nil {
+		t.Fatalf("discovery.New: %s", err)
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	roles, err := StartWithOptions(ctx, d, Options{SilentTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("StartWithOptions: %s", err)
+	}
+
+	// With no peers at all, we promote ourselves to primary on the first
+	// check. Checks run every SilentTimeout/5: 4ms here, against the 1s
+	// that the default 5s timeout would give.
+	waitForRole(t, roles, RolePrimary)
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("became primary after %s, want well under the 1s check interval of the default timeout", elapsed)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-roles:
+		if ok {
+			t.Fatalf("expected the role channel to be closed after cancellation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for the role channel to close")
+	}
+}
+
+// fakeSource is a peerSource whose peer table can be mutated by tests to
+// simulate peers going silent or being heard from again.
+type fakeSource struct {
+	mu    sync.Mutex
+	self  string
+	peers map[string]discovery.Peer
+}
+
+func newFakeSource(self string) *fakeSource {
+	return &fakeSource{self: self, peers: make(map[string]discovery.Peer)}
+}
+
+func (f *fakeSource) SelfID() string { return f.self }
+
+func (f *fakeSource) Peers() []discovery.Peer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]discovery.Peer, 0, len(f.peers))
+	for _, p := range f.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (f *fakeSource) seen(id string, lastSeen time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peers[id] = discovery.Peer{ID: id, LastSeen: lastSeen}
+}
+
+func (f *fakeSource) forget(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.peers, id)
+}
+
+func waitForRole(t *testing.T, roles <-chan Role, want Role) {
+	t.Helper()
+	select {
+	case got, ok := <-roles:
+		if !ok {
+			t.Fatalf("role channel closed before emitting %s", want)
+		}
+		if got != want {
+			t.Fatalf("got role %s, want %s", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for role %s", want)
+	}
+}
+
+func TestElect_LowestPeerIDWins(t *testing.T) {
+	src := newFakeSource("peer-b")
+	src.seen("peer-a", time.Now())
+	src.seen("peer-c", time.Now())
+
+	if got := elect(src, time.Second); got != RoleBackup {
+		t.Fatalf("got %s, want %s", got, RoleBackup)
+	}
+
+	src.forget("peer-a")
+	if got := elect(src, time.Second); got != RolePrimary {
+		t.Fatalf("got %s, want %s", got, RolePrimary)
+	}
+}
+
+func TestStart_PrimaryLossTriggersReElection(t *testing.T) {
+	src := newFakeSource("peer-b")
+	src.seen("peer-a", time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	roles, err := startWithTimeout(ctx, src, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("startWithTimeout: %s", err)
+	}
+
+	waitForRole(t, roles, RoleBackup)
+
+	// Stop refreshing peer-a's last-heard time; once it exceeds the
+	// silent timeout we should be promoted to primary.
+	time.Sleep(100 * time.Millisecond)
+	waitForRole(t, roles, RolePrimary)
+}
+
+func TestStart_SplitBrainHeals(t *testing.T) {
+	src := newFakeSource("peer-b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	roles, err := startWithTimeout(ctx, src, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("startWithTimeout: %s", err)
+	}
+
+	// No one else around: we believe we're primary (as would happen on
+	// each side of a network partition).
+	waitForRole(t, roles, RolePrimary)
+
+	// The partition heals and we hear from a peer with a lower ID: we
+	// should step down to backup rather than both sides staying primary.
+	src.seen("peer-a", time.Now())
+	waitForRole(t, roles, RoleBackup)
+}
@@ -0,0 +1,70 @@
+package nameserver
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// Server answers DNS queries from a Zone. It implements dns.Handler so it
+// can be plugged straight into a *dns.Server.
+type Server struct {
+	zone *Zone
+}
+
+// NewServer builds a Server backed by zone.
+func NewServer(zone *Zone) *Server {
+	return &Server{zone: zone}
+}
+
+// ServeDNS implements dns.Handler.
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+
+	for _, q := range req.Question {
+		for _, ans := range s.zone.Lookup(q.Name) {
+			rr, err := recordFor(q, ans)
+			if err != nil {
+				continue // e.g. an AAAA record for an A query
+			}
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	if len(resp.Answer) == 0 {
+		resp.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(resp)
+}
+
+// recordFor builds the dns.RR matching q's question type for ans, using
+// ans.TTL as the response's TTL, or an error if ans.Address's family
+// doesn't match the question type.
+func recordFor(q dns.Question, ans Answer) (dns.RR, error) {
+	header := dns.RR_Header{Name: q.Name, Class: dns.ClassINET, Ttl: ans.TTL}
+	switch q.Qtype {
+	case dns.TypeA:
+		ip4 := ans.Address.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("%s has no IPv4 address for an A record", q.Name)
+		}
+		header.Rrtype = dns.TypeA
+		return &dns.A{Hdr: header, A: ip4}, nil
+	case dns.TypeAAAA:
+		if ans.Address.To4() != nil {
+			return nil, fmt.Errorf("%s has no IPv6 address for an AAAA record", q.Name)
+		}
+		header.Rrtype = dns.TypeAAAA
+		return &dns.AAAA{Hdr: header, AAAA: ans.Address}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query type %s for %s", dns.TypeToString[q.Qtype], q.Name)
+	}
+}
+
+// ListenAndServe serves DNS over UDP on addr (typically ":53") until it
+// fails or is shut down.
+func (s *Server) ListenAndServe(addr string) error {
+	srv := &dns.Server{Addr: addr, Net: "udp", Handler: s}
+	return srv.ListenAndServe()
+}
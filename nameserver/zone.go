@@ -0,0 +1,162 @@
+// Package nameserver turns the DNS records peers announce over discovery
+// into a merged, zero-config LAN zone and serves it over UDP using
+// github.com/miekg/dns.
+package nameserver
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/astromechza/experiment-dhcp-nameservers/discovery"
+)
+
+// ConflictPolicy decides which record wins when more than one peer
+// announces the same hostname.
+type ConflictPolicy int
+
+const (
+	// LowestPeerWins keeps only the record from the peer with the
+	// lexicographically lowest peer ID, mirroring the tiebreaker the
+	// election package uses for primary selection.
+	LowestPeerWins ConflictPolicy = iota
+	// RoundRobin returns every announced address for a hostname, cycling
+	// through them one at a time across successive queries.
+	RoundRobin
+)
+
+// entry is one peer's announced record for a hostname, with the deadline
+// it's valid until.
+type entry struct {
+	peerID  string
+	record  discovery.Record
+	expires time.Time
+}
+
+// peerTable is the subset of *discovery.Discovery the zone depends on.
+// It's split out so tests can drive the merge/conflict logic against a
+// fake peer table instead of real sockets.
+type peerTable interface {
+	Peers() []discovery.Peer
+	Events() <-chan discovery.Event
+}
+
+// Zone is a continuously-refreshed, merged view of the DNS records
+// announced by every known peer.
+type Zone struct {
+	d      peerTable
+	policy ConflictPolicy
+
+	mu     sync.Mutex
+	byName map[string][]entry
+	cursor map[string]int
+}
+
+// NewZone builds a Zone backed by d's peer table. Call Run to keep it
+// up to date.
+func NewZone(d *discovery.Discovery, policy ConflictPolicy) *Zone {
+	return newZone(d, policy)
+}
+
+func newZone(d peerTable, policy ConflictPolicy) *Zone {
+	return &Zone{
+		d:      d,
+		policy: policy,
+		byName: make(map[string][]entry),
+		cursor: make(map[string]int),
+	}
+}
+
+// Run rebuilds the zone from the peer table whenever a peer-table event
+// arrives, and once every interval regardless, until ctx is cancelled. It
+// consumes d's event channel, so don't also range over d.Events()
+// elsewhere while Run is active.
+func (z *Zone) Run(ctx context.Context, interval time.Duration) {
+	z.refresh()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			z.refresh()
+		case _, ok := <-z.d.Events():
+			if !ok {
+				return
+			}
+			z.refresh()
+		}
+	}
+}
+
+func (z *Zone) refresh() {
+	now := time.Now()
+	byName := make(map[string][]entry)
+	for _, p := range z.d.Peers() {
+		for _, r := range p.Records {
+			expires := p.LastSeen.Add(time.Duration(r.TTL) * time.Second)
+			if expires.Before(now) {
+				continue
+			}
+			name := dns.Fqdn(r.Hostname)
+			byName[name] = append(byName[name], entry{peerID: p.ID, record: r, expires: expires})
+		}
+	}
+	z.mu.Lock()
+	z.byName = byName
+	z.mu.Unlock()
+}
+
+// Answer is a single resolved record: the address to answer with and the
+// TTL (in seconds) a client should cache it for, derived from how much
+// longer the underlying announcement has left to live.
+type Answer struct {
+	Address net.IP
+	TTL     uint32
+}
+
+// Lookup returns the answers for a query for name, applying the zone's
+// conflict policy. It returns nil if name isn't known.
+func (z *Zone) Lookup(name string) []Answer {
+	name = dns.Fqdn(name)
+	now := time.Now()
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	entries := z.byName[name]
+	if len(entries) == 0 {
+		return nil
+	}
+
+	switch z.policy {
+	case RoundRobin:
+		sorted := append([]entry(nil), entries...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].peerID < sorted[j].peerID })
+		i := z.cursor[name] % len(sorted)
+		z.cursor[name] = i + 1
+		return []Answer{answerFrom(sorted[i], now)}
+	default: // LowestPeerWins
+		winner := entries[0]
+		for _, e := range entries[1:] {
+			if e.peerID < winner.peerID {
+				winner = e
+			}
+		}
+		return []Answer{answerFrom(winner, now)}
+	}
+}
+
+// answerFrom converts e into an Answer, deriving its TTL from the time
+// remaining until the underlying announcement expires.
+func answerFrom(e entry, now time.Time) Answer {
+	remaining := e.expires.Sub(now)
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	return Answer{Address: e.record.Address, TTL: uint32(remaining / time.Second)}
+}
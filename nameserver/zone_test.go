@@ -0,0 +1,103 @@
+package nameserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/astromechza/experiment-dhcp-nameservers/discovery"
+)
+
+// fakeTable is a peerTable whose peer list is fixed at construction time,
+// used to drive Zone's merge/conflict logic without real sockets.
+type fakeTable struct {
+	peers []discovery.Peer
+}
+
+func (f fakeTable) Peers() []discovery.Peer        { return f.peers }
+func (f fakeTable) Events() <-chan discovery.Event { return nil }
+
+func TestZone_LowestPeerWins(t *testing.T) {
+	now := time.Now()
+	table := fakeTable{peers: []discovery.Peer{
+		{ID: "peer-b", LastSeen: now, Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.2"), TTL: 300},
+		}},
+		{ID: "peer-a", LastSeen: now, Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.1"), TTL: 300},
+		}},
+	}}
+
+	z := newZone(table, LowestPeerWins)
+	z.refresh()
+
+	got := z.Lookup("box")
+	if len(got) != 1 || !got[0].Address.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("got %v, want [10.0.0.1] (peer-a's record)", got)
+	}
+}
+
+func TestZone_RoundRobinCyclesAddresses(t *testing.T) {
+	now := time.Now()
+	table := fakeTable{peers: []discovery.Peer{
+		{ID: "peer-a", LastSeen: now, Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.1"), TTL: 300},
+		}},
+		{ID: "peer-b", LastSeen: now, Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.2"), TTL: 300},
+		}},
+	}}
+
+	z := newZone(table, RoundRobin)
+	z.refresh()
+
+	first := z.Lookup("box")
+	second := z.Lookup("box")
+	third := z.Lookup("box")
+
+	if len(first) != 1 || len(second) != 1 || len(third) != 1 {
+		t.Fatalf("expected a single address per lookup, got %v %v %v", first, second, third)
+	}
+	if first[0].Address.Equal(second[0].Address) {
+		t.Fatalf("expected round-robin to alternate addresses, got %v twice", first[0].Address)
+	}
+	if !first[0].Address.Equal(third[0].Address) {
+		t.Fatalf("expected round-robin to cycle back after 2 peers, got %v then %v", first[0].Address, third[0].Address)
+	}
+}
+
+func TestZone_ExpiredRecordIsDropped(t *testing.T) {
+	table := fakeTable{peers: []discovery.Peer{
+		{ID: "peer-a", LastSeen: time.Now().Add(-time.Hour), Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.1"), TTL: 1},
+		}},
+	}}
+
+	z := newZone(table, LowestPeerWins)
+	z.refresh()
+
+	if got := z.Lookup("box"); got != nil {
+		t.Fatalf("got %v, want nil for an expired record", got)
+	}
+}
+
+func TestZone_LookupTTLReflectsRemainingLifetime(t *testing.T) {
+	table := fakeTable{peers: []discovery.Peer{
+		{ID: "peer-a", LastSeen: time.Now().Add(-100 * time.Second), Records: []discovery.Record{
+			{Hostname: "box", Address: net.ParseIP("10.0.0.1"), TTL: 300},
+		}},
+	}}
+
+	z := newZone(table, LowestPeerWins)
+	z.refresh()
+
+	got := z.Lookup("box")
+	if len(got) != 1 {
+		t.Fatalf("got %d answers, want 1", len(got))
+	}
+	// Announced with a 300s TTL, 100s ago: roughly 200s of life left, not
+	// the full 300s the peer originally announced.
+	if got[0].TTL >= 300 || got[0].TTL < 190 {
+		t.Fatalf("got TTL %d, want it to reflect ~200s of remaining lifetime", got[0].TTL)
+	}
+}